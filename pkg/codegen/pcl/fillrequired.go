@@ -0,0 +1,307 @@
+// Copyright 2016-2020, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pcl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/model"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// Edit is a single textual replacement to be applied to a program's source. Tools such as
+// `pulumi convert` and language plugins apply Edits directly to the bytes backing Filename;
+// a zero-length Range indicates an insertion rather than a replacement.
+type Edit struct {
+	// Filename identifies the source file the edit applies to, matching a key of Program.Source().
+	Filename string
+	// Range is the span of text in Filename that NewText replaces.
+	Range hcl.Range
+	// NewText is the HCL2 source to insert in place of Range.
+	NewText string
+}
+
+// FillRequiredInputs inspects a single resource node and returns the edits needed to add zero-value
+// expressions for every required input property that is missing from its body. It is the pcl
+// analogue of gopls' fillstruct/fillreturns analyzers: rather than leaving the program unbound
+// because a required property is absent, callers can offer these edits as a quick fix.
+func (p *Program) FillRequiredInputs(node Node) ([]Edit, hcl.Diagnostics) {
+	resource, ok := node.(*Resource)
+	if !ok || resource.Schema == nil {
+		return nil, nil
+	}
+
+	present := map[string]bool{}
+	for _, attr := range resource.Inputs {
+		present[attr.Name] = true
+	}
+
+	required := map[string]bool{}
+	for _, name := range resource.Schema.RequiredInputs {
+		required[name] = true
+	}
+
+	var diagnostics hcl.Diagnostics
+	memo := map[schema.Type]model.Expression{}
+	var items []model.ObjectConsItem
+	for _, prop := range resource.Schema.InputProperties {
+		if present[prop.Name] || !required[prop.Name] {
+			continue
+		}
+		if prop.ConstValue != nil || prop.DefaultValue != nil {
+			continue
+		}
+
+		expr, propDiags := zeroValueExpression(prop.Name, prop.Type, memo)
+		diagnostics = append(diagnostics, propDiags...)
+		items = append(items, model.ObjectConsItem{
+			Key:   &model.LiteralValueExpression{Value: cty.StringVal(prop.Name)},
+			Value: expr,
+		})
+	}
+
+	if len(items) == 0 {
+		return nil, diagnostics
+	}
+
+	insertAt := resource.Definition.Body.SrcRange.End
+	return []Edit{{
+		Filename: insertAt.Filename,
+		Range:    hcl.Range{Filename: insertAt.Filename, Start: insertAt, End: insertAt},
+		NewText:  attributesSource(items),
+	}}, diagnostics
+}
+
+// FillReturnedOutputs suggests `output` blocks for the properties of a resource that are
+// commonly exported, i.e. ones that are not marked secret, are named `id`, `arn` or `name` or end
+// in `Id` or `Arn`, and are not already surfaced by an existing OutputVariable bound to the resource.
+func (p *Program) FillReturnedOutputs(node Node) ([]Edit, hcl.Diagnostics) {
+	resource, ok := node.(*Resource)
+	if !ok || resource.Schema == nil {
+		return nil, nil
+	}
+
+	exported := map[string]bool{}
+	for _, output := range p.OutputVariables() {
+		if ref, ok := output.Value.(*model.ScopeTraversalExpression); ok && len(ref.Parts) > 0 {
+			if ref.RootName == resource.Name() {
+				exported[propertyNameFromTraversal(ref)] = true
+			}
+		}
+	}
+
+	var edits []Edit
+	var diagnostics hcl.Diagnostics
+	// Output blocks are top-level PCL declarations, so the insertion point is after the
+	// resource block's own closing brace, not after the closing brace of its body.
+	insertAt := resource.Definition.SrcRange.End
+	for _, prop := range resource.Schema.Properties {
+		if !isCommonlyExported(prop) || exported[prop.Name] {
+			continue
+		}
+
+		name := fmt.Sprintf("%s%s", resource.Name(), strings.Title(prop.Name))
+		text := fmt.Sprintf("\noutput %s {\n  value = %s.%s\n}\n", name, resource.Name(), prop.Name)
+		edits = append(edits, Edit{
+			Filename: insertAt.Filename,
+			Range:    hcl.Range{Filename: insertAt.Filename, Start: insertAt, End: insertAt},
+			NewText:  text,
+		})
+	}
+
+	return edits, diagnostics
+}
+
+// zeroValueExpression synthesizes an HCL2 expression representing the zero value of t, matching
+// it to the property's schema.Type. Object types are filled recursively; memo ensures that
+// self-referential object types terminate rather than recursing forever.
+func zeroValueExpression(propertyName string, t schema.Type, memo map[schema.Type]model.Expression) (model.Expression, hcl.Diagnostics) {
+	t = codegen.UnwrapType(t)
+
+	if expr, ok := memo[t]; ok {
+		return expr, nil
+	}
+
+	switch t := t.(type) {
+	case *schema.EnumType:
+		if len(t.Elements) == 0 {
+			return stringLiteral(""), nil
+		}
+		return enumValueExpression(t.Elements[0]), nil
+	case *schema.ArrayType:
+		return &model.TupleConsExpression{Expressions: []model.Expression{}}, nil
+	case *schema.MapType:
+		return &model.ObjectConsExpression{}, nil
+	case *schema.ObjectType:
+		// Reserve the memo entry before recursing so that a property whose type refers back to
+		// this same object type resolves to an empty object rather than looping forever.
+		memo[t] = &model.ObjectConsExpression{}
+		result := &model.ObjectConsExpression{}
+		var diagnostics hcl.Diagnostics
+		for _, prop := range t.Properties {
+			if isRequiredObjectProperty(t, prop) {
+				value, propDiags := zeroValueExpression(prop.Name, prop.Type, memo)
+				diagnostics = append(diagnostics, propDiags...)
+				result.Items = append(result.Items, model.ObjectConsItem{
+					Key:   &model.LiteralValueExpression{Value: cty.StringVal(prop.Name)},
+					Value: value,
+				})
+			}
+		}
+		memo[t] = result
+		return result, diagnostics
+	case *schema.UnionType:
+		if len(t.ElementTypes) == 0 {
+			return stringLiteral(""), nil
+		}
+		return zeroValueExpression(propertyName, t.ElementTypes[0], memo)
+	default:
+		switch t {
+		case schema.StringType:
+			return stringLiteral(""), nil
+		case schema.BoolType:
+			return &model.LiteralValueExpression{Value: cty.False}, nil
+		case schema.IntType:
+			return &model.LiteralValueExpression{Value: cty.NumberIntVal(0)}, nil
+		case schema.NumberType:
+			return &model.LiteralValueExpression{Value: cty.NumberIntVal(0)}, nil
+		case schema.AssetType:
+			return sentinelCall("fileAsset", stringLiteral("")), nil
+		case schema.ArchiveType:
+			return sentinelCall("fileArchive", stringLiteral("")), nil
+		}
+	}
+
+	return stringLiteral(""), hcl.Diagnostics{{
+		Severity: hcl.DiagWarning,
+		Summary:  "unable to synthesize a zero value",
+		Detail:   fmt.Sprintf("no known zero value for property %q of type %v", propertyName, t),
+	}}
+}
+
+func isRequiredObjectProperty(t *schema.ObjectType, prop *schema.Property) bool {
+	for _, name := range t.Required {
+		if name == prop.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func isCommonlyExported(prop *schema.Property) bool {
+	return !prop.Secret && (prop.Name == "id" || prop.Name == "arn" || prop.Name == "name" ||
+		strings.HasSuffix(prop.Name, "Id") || strings.HasSuffix(prop.Name, "Arn"))
+}
+
+func propertyNameFromTraversal(ref *model.ScopeTraversalExpression) string {
+	if len(ref.Traversal) == 0 {
+		return ""
+	}
+	if attr, ok := ref.Traversal[len(ref.Traversal)-1].(hcl.TraverseAttr); ok {
+		return attr.Name
+	}
+	return ""
+}
+
+func enumValueExpression(e *schema.Enum) model.Expression {
+	switch v := e.Value.(type) {
+	case string:
+		return stringLiteral(v)
+	case float64:
+		return &model.LiteralValueExpression{Value: cty.NumberFloatVal(v)}
+	default:
+		return stringLiteral(fmt.Sprintf("%v", v))
+	}
+}
+
+func stringLiteral(s string) model.Expression {
+	return &model.LiteralValueExpression{Value: cty.StringVal(s)}
+}
+
+func sentinelCall(name string, args ...model.Expression) model.Expression {
+	return &model.FunctionCallExpression{
+		Name: name,
+		Args: args,
+	}
+}
+
+// attributesSource renders object-construction items as `key = value` HCL2 attributes, one per
+// line, suitable for insertion into an existing resource body. The insertion point this feeds
+// sits immediately before the body's closing brace, so the result must end in its own newline
+// or the last attribute would run directly into that brace.
+func attributesSource(items []model.ObjectConsItem) string {
+	var b strings.Builder
+	for _, item := range items {
+		key, ok := item.Key.(*model.LiteralValueExpression)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "\n  %s = %s", key.Value.AsString(), exprSource(item.Value))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// exprSource renders one of the synthetic expressions produced by zeroValueExpression back into
+// HCL2 source text. It only needs to handle the small set of expression kinds this file
+// generates, not the full model.Expression grammar.
+func exprSource(expr model.Expression) string {
+	switch expr := expr.(type) {
+	case *model.LiteralValueExpression:
+		v := expr.Value
+		switch {
+		case v.Type() == cty.String:
+			return fmt.Sprintf("%q", v.AsString())
+		case v.Type() == cty.Bool:
+			if v.True() {
+				return "true"
+			}
+			return "false"
+		default:
+			return v.AsBigFloat().Text('f', -1)
+		}
+	case *model.TupleConsExpression:
+		parts := make([]string, len(expr.Expressions))
+		for i, e := range expr.Expressions {
+			parts[i] = exprSource(e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *model.ObjectConsExpression:
+		if len(expr.Items) == 0 {
+			return "{}"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, item := range expr.Items {
+			key, _ := item.Key.(*model.LiteralValueExpression)
+			fmt.Fprintf(&b, "    %s = %s\n", key.Value.AsString(), exprSource(item.Value))
+		}
+		b.WriteString("  }")
+		return b.String()
+	case *model.FunctionCallExpression:
+		args := make([]string, len(expr.Args))
+		for i, a := range expr.Args {
+			args[i] = exprSource(a)
+		}
+		return fmt.Sprintf("%s(%s)", expr.Name, strings.Join(args, ", "))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2016-2020, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pcl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/blang/semver"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/hcl2/model"
+)
+
+// Severity classifies how serious a package advisory is, ordered from least to most severe.
+type Severity int
+
+const (
+	SeverityLow Severity = iota
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+// Advisory describes a single known vulnerability affecting a range of versions of a package.
+type Advisory struct {
+	// ID is the advisory's identifier, e.g. a GHSA or CVE number.
+	ID string `json:"id"`
+	// Severity is how serious the advisory is.
+	Severity Severity `json:"severity"`
+	// AffectedRange is the semver range of affected versions, e.g. "<1.2.3".
+	AffectedRange string `json:"affectedRange"`
+	// Summary is a short, human-readable description of the vulnerability.
+	Summary string `json:"summary"`
+	// URL links to the full advisory, if any.
+	URL string `json:"url,omitempty"`
+}
+
+// VulnDB looks up known advisories affecting a given package name and version. Implementations
+// may be backed by a static file, an HTTP endpoint, or any other source.
+type VulnDB interface {
+	// Lookup returns the advisories known to affect the given package version. It returns an
+	// empty slice, not an error, when the package is known but has no matching advisories.
+	Lookup(pkgName, version string) ([]Advisory, error)
+}
+
+// PackageAdvisory pairs a package referenced by a program with one advisory that affects the
+// version the program resolved to, along with the source locations that reference the package.
+type PackageAdvisory struct {
+	// Package is the name of the referenced package, e.g. "aws".
+	Package string
+	// Version is the resolved version of the package, as declared in the program's dependencies.
+	Version string
+	// Advisory is the matched advisory.
+	Advisory Advisory
+	// Ranges are the source locations where the package is referenced, e.g. resource
+	// declarations bound to one of its types.
+	Ranges []hcl.Range
+}
+
+// CheckPackageVulnerabilities looks up every package referenced by the program, including those
+// referenced transitively through component programs, against db and returns the advisories that
+// apply at or above minSeverity. It lets `pulumi preview` warn when a stack pins a provider
+// version with a known CVE without requiring users to hand-audit their plugin set.
+func (p *Program) CheckPackageVulnerabilities(
+	ctx context.Context, db VulnDB, minSeverity Severity,
+) ([]PackageAdvisory, error) {
+	packages, err := p.CollectNestedPackageSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("collecting referenced packages: %w", err)
+	}
+
+	ranges := p.packageReferenceRanges()
+
+	var advisories []PackageAdvisory
+	var lookupErrs []error
+	for name, pkg := range packages {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		version := ""
+		if pkg.Version != nil {
+			version = pkg.Version.String()
+		}
+		if version == "" {
+			// Local/unversioned package references have nothing to check against a VulnDB keyed
+			// by version; skip them rather than failing the whole scan.
+			continue
+		}
+
+		found, err := db.Lookup(name, version)
+		if err != nil {
+			// A single package whose version this VulnDB can't resolve shouldn't abort the scan
+			// for the rest of the program's referenced packages, but the failure is still
+			// reported so callers don't mistake it for a clean scan.
+			lookupErrs = append(lookupErrs, fmt.Errorf("looking up advisories for %s@%s: %w", name, version, err))
+			continue
+		}
+
+		for _, advisory := range found {
+			if advisory.Severity < minSeverity {
+				continue
+			}
+			advisories = append(advisories, PackageAdvisory{
+				Package:  name,
+				Version:  version,
+				Advisory: advisory,
+				Ranges:   ranges[name],
+			})
+		}
+	}
+
+	return advisories, errors.Join(lookupErrs...)
+}
+
+// packageReferenceRanges derives, for each referenced package name, the set of source ranges in
+// this program, and any programs of its nested components, where either a resource bound to
+// that package is declared or an `invoke` call into that package is made. CollectComponents
+// already returns the fully-flattened set of descendant components, so each one's own nodes are
+// visited exactly once rather than recursing further and re-visiting deeper components twice.
+func (p *Program) packageReferenceRanges() map[string][]hcl.Range {
+	ranges := map[string][]hcl.Range{}
+	collectNodeReferenceRanges(p.Nodes, ranges)
+	for _, component := range p.CollectComponents() {
+		collectNodeReferenceRanges(component.Program.Nodes, ranges)
+	}
+	return ranges
+}
+
+func collectNodeReferenceRanges(nodes []Node, ranges map[string][]hcl.Range) {
+	for _, node := range nodes {
+		if resource, ok := node.(*Resource); ok && resource.Schema != nil && resource.Schema.Package != nil {
+			name := resource.Schema.Package.Name
+			ranges[name] = append(ranges[name], resource.Definition.Body.SrcRange)
+		}
+
+		node.VisitExpressions(nil, func(x model.Expression) (model.Expression, hcl.Diagnostics) {
+			call, ok := x.(*model.FunctionCallExpression)
+			if !ok || call.Name != "invoke" || len(call.Args) == 0 {
+				return x, nil
+			}
+
+			token, ok := call.Args[0].(*model.LiteralValueExpression)
+			if !ok || token.Value.Type() != cty.String {
+				return x, nil
+			}
+
+			if name := packageNameFromToken(token.Value.AsString()); name != "" {
+				ranges[name] = append(ranges[name], call.SyntaxNode().Range())
+			}
+			return x, nil
+		})
+	}
+}
+
+// packageNameFromToken extracts the package name from a Pulumi schema token of the form
+// "pkg:module:member", e.g. "aws:ec2/getAmi:getAmi" resolves to "aws".
+func packageNameFromToken(token string) string {
+	if i := strings.IndexByte(token, ':'); i >= 0 {
+		return token[:i]
+	}
+	return token
+}
+
+// cacheKey identifies a (package, version) pair in a VulnDB's in-memory cache.
+type cacheKey struct {
+	pkg     string
+	version string
+}
+
+// staticVulnDB is a VulnDB backed by a fixed set of advisories, keyed by package name, loaded
+// once from disk or an HTTP endpoint. Lookups are served from an in-memory cache so that
+// repeated calls for the same (package, version) pair, e.g. across a program's resources, don't
+// re-evaluate the semver range each time.
+type staticVulnDB struct {
+	advisories map[string][]Advisory
+
+	mu    sync.Mutex
+	cache map[cacheKey][]Advisory
+}
+
+// NewFileVulnDB loads a static set of advisories from a JSON or YAML document on disk. The
+// document is a map from package name to the list of advisories known to affect it.
+func NewFileVulnDB(path string) (VulnDB, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading vulnerability database %q: %w", path, err)
+	}
+	return newStaticVulnDB(contents)
+}
+
+// NewHTTPVulnDB fetches a static set of advisories, in the same format as NewFileVulnDB, from an
+// HTTP endpoint. The response is fetched once, at construction time; callers that need to pick
+// up updates should construct a new VulnDB periodically.
+func NewHTTPVulnDB(ctx context.Context, url string) (VulnDB, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for vulnerability database %q: %w", url, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching vulnerability database %q: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching vulnerability database %q: unexpected status %s", url, resp.Status)
+	}
+
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading vulnerability database %q: %w", url, err)
+	}
+	return newStaticVulnDB(contents)
+}
+
+// newStaticVulnDB parses contents as either JSON or YAML, via sigs.k8s.io/yaml (valid JSON is
+// valid YAML, so one decode path covers both formats) into a map from package name to the
+// advisories known to affect it.
+func newStaticVulnDB(contents []byte) (VulnDB, error) {
+	var advisories map[string][]Advisory
+	if err := yaml.Unmarshal(contents, &advisories); err != nil {
+		return nil, fmt.Errorf("parsing vulnerability database: %w", err)
+	}
+	return &staticVulnDB{
+		advisories: advisories,
+		cache:      map[cacheKey][]Advisory{},
+	}, nil
+}
+
+func (db *staticVulnDB) Lookup(pkgName, version string) ([]Advisory, error) {
+	key := cacheKey{pkg: pkgName, version: version}
+
+	db.mu.Lock()
+	if cached, ok := db.cache[key]; ok {
+		db.mu.Unlock()
+		return cached, nil
+	}
+	db.mu.Unlock()
+
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return nil, fmt.Errorf("parsing version %q for package %q: %w", version, pkgName, err)
+	}
+
+	var matches []Advisory
+	for _, advisory := range db.advisories[pkgName] {
+		affected, err := semver.ParseRange(advisory.AffectedRange)
+		if err != nil {
+			return nil, fmt.Errorf("parsing affected range %q for advisory %s: %w", advisory.AffectedRange, advisory.ID, err)
+		}
+		if affected(v) {
+			matches = append(matches, advisory)
+		}
+	}
+
+	db.mu.Lock()
+	db.cache[key] = matches
+	db.mu.Unlock()
+
+	return matches, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2016-2020, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pcl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+func testDB(t *testing.T) VulnDB {
+	t.Helper()
+
+	db, err := newStaticVulnDB([]byte(`{
+		"aws": [
+			{"id": "GHSA-aws-1", "severity": 2, "affectedRange": "<5.0.0", "summary": "old aws provider"},
+			{"id": "GHSA-aws-2", "severity": 0, "affectedRange": "<6.0.0", "summary": "minor aws issue"}
+		]
+	}`))
+	require.NoError(t, err)
+	return db
+}
+
+func TestStaticVulnDBLookupMatchesAffectedRange(t *testing.T) {
+	t.Parallel()
+
+	db := testDB(t)
+
+	advisories, err := db.Lookup("aws", "4.0.0")
+	require.NoError(t, err)
+	assert.Len(t, advisories, 2)
+
+	advisories, err = db.Lookup("aws", "5.5.0")
+	require.NoError(t, err)
+	require.Len(t, advisories, 1)
+	assert.Equal(t, "GHSA-aws-2", advisories[0].ID)
+
+	advisories, err = db.Lookup("aws", "6.0.0")
+	require.NoError(t, err)
+	assert.Empty(t, advisories)
+}
+
+func TestStaticVulnDBLookupUnknownPackage(t *testing.T) {
+	t.Parallel()
+
+	db := testDB(t)
+
+	advisories, err := db.Lookup("azure", "1.0.0")
+	require.NoError(t, err)
+	assert.Empty(t, advisories)
+}
+
+func TestStaticVulnDBLookupUnparseableVersion(t *testing.T) {
+	t.Parallel()
+
+	db := testDB(t)
+
+	_, err := db.Lookup("aws", "")
+	assert.Error(t, err)
+}
+
+func TestStaticVulnDBLookupCachesResults(t *testing.T) {
+	t.Parallel()
+
+	db := testDB(t).(*staticVulnDB)
+
+	first, err := db.Lookup("aws", "4.0.0")
+	require.NoError(t, err)
+
+	key := cacheKey{pkg: "aws", version: "4.0.0"}
+	cached, ok := db.cache[key]
+	require.True(t, ok)
+	assert.Equal(t, first, cached)
+
+	// Mutate the underlying advisories; a cached lookup should not observe the change.
+	db.advisories["aws"] = nil
+
+	second, err := db.Lookup("aws", "4.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+}
+
+func TestStaticVulnDBLookupMalformedAffectedRange(t *testing.T) {
+	t.Parallel()
+
+	db, err := newStaticVulnDB([]byte(`{
+		"broken": [
+			{"id": "GHSA-broken-1", "severity": 2, "affectedRange": "not-a-range", "summary": "bad data"}
+		]
+	}`))
+	require.NoError(t, err)
+
+	// A malformed advisory in the database surfaces as an error from Lookup rather than being
+	// silently ignored, so CheckPackageVulnerabilities can report the failed package instead of
+	// treating it as clean.
+	_, err = db.Lookup("broken", "1.0.0")
+	assert.Error(t, err)
+}
+
+func TestPackageNameFromToken(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "aws", packageNameFromToken("aws:ec2/getAmi:getAmi"))
+	assert.Equal(t, "aws", packageNameFromToken("aws"))
+	assert.Equal(t, "", packageNameFromToken(""))
+}
+
+func TestNewStaticVulnDBParsesYAML(t *testing.T) {
+	t.Parallel()
+
+	db, err := newStaticVulnDB([]byte(`
+aws:
+  - id: GHSA-aws-1
+    severity: 2
+    affectedRange: "<5.0.0"
+    summary: old aws provider
+`))
+	require.NoError(t, err)
+
+	advisories, err := db.Lookup("aws", "4.0.0")
+	require.NoError(t, err)
+	require.Len(t, advisories, 1)
+	assert.Equal(t, "GHSA-aws-1", advisories[0].ID)
+}
+
+// fakePackageReference is a minimal stand-in for schema.PackageReference, implementing only the
+// methods this package actually calls (Name and Definition). The full set of PackageReference
+// implementations lives in the schema package, which this checkout doesn't carry.
+type fakePackageReference struct {
+	pkg *schema.Package
+}
+
+func (f *fakePackageReference) Name() string                         { return f.pkg.Name }
+func (f *fakePackageReference) Definition() (*schema.Package, error) { return f.pkg, nil }
+
+func versionedPackageRef(name, version string) schema.PackageReference {
+	v := semver.MustParse(version)
+	return &fakePackageReference{pkg: &schema.Package{Name: name, Version: &v}}
+}
+
+func unversionedPackageRef(name string) schema.PackageReference {
+	return &fakePackageReference{pkg: &schema.Package{Name: name}}
+}
+
+// erroringVulnDB wraps a VulnDB and forces a failure for one package name, so tests can drive
+// CheckPackageVulnerabilities' partial-failure path.
+type erroringVulnDB struct {
+	base   VulnDB
+	failOn string
+	err    error
+}
+
+func (e *erroringVulnDB) Lookup(pkgName, version string) ([]Advisory, error) {
+	if pkgName == e.failOn {
+		return nil, e.err
+	}
+	return e.base.Lookup(pkgName, version)
+}
+
+// TestCheckPackageVulnerabilitiesEndToEnd drives the public entry point directly: one package
+// resolves advisories filtered by minSeverity, one has no pinned version and is skipped, and one
+// fails its lookup -- which must surface in the returned error without losing the other
+// package's results.
+func TestCheckPackageVulnerabilitiesEndToEnd(t *testing.T) {
+	t.Parallel()
+
+	db := &erroringVulnDB{base: testDB(t), failOn: "broken", err: errors.New("lookup boom")}
+
+	program := &Program{
+		binder: &binder{
+			referencedPackages: map[string]schema.PackageReference{
+				"aws":         versionedPackageRef("aws", "4.0.0"),
+				"unversioned": unversionedPackageRef("unversioned"),
+				"broken":      versionedPackageRef("broken", "1.0.0"),
+			},
+		},
+	}
+
+	advisories, err := program.CheckPackageVulnerabilities(context.Background(), db, SeverityMedium)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+
+	require.Len(t, advisories, 1)
+	assert.Equal(t, "aws", advisories[0].Package)
+	assert.Equal(t, "GHSA-aws-1", advisories[0].Advisory.ID)
+}